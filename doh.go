@@ -1,20 +1,31 @@
 package ctrld
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/lucas-clemente/quic-go/http3"
 	"github.com/miekg/dns"
 )
 
+// dohMaxGetSize is the packed query size above which "auto" mode
+// switches from GET to POST, per RFC 8484 guidance that intermediaries
+// commonly cap URLs around 2-4 KB.
+const dohMaxGetSize = 2048
+
 func newDohResolver(uc *UpstreamConfig) *dohResolver {
 	r := &dohResolver{
 		endpoint:          uc.Endpoint,
 		isDoH3:            uc.Type == ResolverTypeDOH3,
+		method:            uc.DOHMethod,
 		transport:         uc.transport,
 		http3RoundTripper: uc.http3RoundTripper,
 	}
@@ -24,8 +35,26 @@ func newDohResolver(uc *UpstreamConfig) *dohResolver {
 type dohResolver struct {
 	endpoint          string
 	isDoH3            bool
+	method            string
 	transport         *http.Transport
 	http3RoundTripper *http3.RoundTripper
+
+	// sawHTTP414 remembers that the server once rejected a GET for
+	// having too long a URL, so "auto" mode stops trying GET first.
+	sawHTTP414 atomic.Bool
+
+	// lastMaxAge holds the Cache-Control max-age (in nanoseconds) from
+	// the most recent response, for callers that want to clamp their
+	// own cache entry the same way we clamp the returned RRs' TTLs.
+	// Zero means the last response had no max-age directive, or had
+	// max-age=0.
+	lastMaxAge atomic.Int64
+}
+
+// MaxAge returns the Cache-Control max-age seen on the most recent
+// response, or zero if it had none.
+func (r *dohResolver) MaxAge() time.Duration {
+	return time.Duration(r.lastMaxAge.Load())
 }
 
 func (r *dohResolver) Resolve(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
@@ -33,14 +62,12 @@ func (r *dohResolver) Resolve(ctx context.Context, msg *dns.Msg) (*dns.Msg, erro
 	if err != nil {
 		return nil, err
 	}
-	enc := base64.RawURLEncoding.EncodeToString(data)
-	url := fmt.Sprintf("%s?dns=%s", r.endpoint, enc)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+
+	usePost := r.usePost(len(data))
+	req, err := r.newRequest(ctx, data, usePost)
 	if err != nil {
 		return nil, fmt.Errorf("could not create request: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/dns-message")
-	req.Header.Set("Accept", "application/dns-message")
 
 	c := http.Client{Transport: r.transport}
 	if r.isDoH3 {
@@ -55,6 +82,10 @@ func (r *dohResolver) Resolve(ctx context.Context, msg *dns.Msg) (*dns.Msg, erro
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusRequestURITooLong {
+		r.sawHTTP414.Store(true)
+	}
+
 	buf, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("could not read message from response: %w", err)
@@ -65,5 +96,91 @@ func (r *dohResolver) Resolve(ctx context.Context, msg *dns.Msg) (*dns.Msg, erro
 	}
 
 	answer := new(dns.Msg)
-	return answer, answer.Unpack(buf)
+	if err := answer.Unpack(buf); err != nil {
+		return nil, err
+	}
+
+	if maxAge, ok := cacheControlMaxAge(resp.Header.Get("Cache-Control")); ok {
+		r.lastMaxAge.Store(int64(maxAge))
+		clampMinTTL(answer, maxAge)
+	} else {
+		r.lastMaxAge.Store(0)
+	}
+
+	return answer, nil
+}
+
+// usePost decides whether to send the query as a POST body rather
+// than a GET query parameter, per the configured DOHMethod.
+func (r *dohResolver) usePost(packedSize int) bool {
+	switch strings.ToUpper(r.method) {
+	case "POST":
+		return true
+	case "GET":
+		return false
+	default: // "auto", or unset
+		return packedSize > dohMaxGetSize || r.sawHTTP414.Load()
+	}
+}
+
+func (r *dohResolver) newRequest(ctx context.Context, data []byte, usePost bool) (*http.Request, error) {
+	var req *http.Request
+	var err error
+	if usePost {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/dns-message")
+	} else {
+		enc := base64.RawURLEncoding.EncodeToString(data)
+		url := fmt.Sprintf("%s?dns=%s", r.endpoint, enc)
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/dns-message")
+	}
+	req.Header.Set("Accept", "application/dns-message")
+	return req, nil
+}
+
+// cacheControlMaxAge extracts the max-age directive from a
+// Cache-Control header, as described in RFC 8484 section 5.1.
+func cacheControlMaxAge(header string) (time.Duration, bool) {
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "max-age") {
+			continue
+		}
+		seconds, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || seconds < 0 {
+			continue
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	return 0, false
+}
+
+// clampMinTTL lowers any RR TTL across the answer, authority, and
+// additional sections that exceeds maxAge down to it, so callers
+// caching the response don't outlive what the server told us to. The
+// EDNS0 OPT pseudo-RR, if present in Extra, is left untouched: it
+// repurposes the TTL field for protocol flags, not a cache lifetime.
+func clampMinTTL(msg *dns.Msg, maxAge time.Duration) {
+	ttl := uint32(maxAge / time.Second)
+	clamp := func(rrs []dns.RR) {
+		for _, rr := range rrs {
+			if rr.Header().Rrtype == dns.TypeOPT {
+				continue
+			}
+			if rr.Header().Ttl > ttl {
+				rr.Header().Ttl = ttl
+			}
+		}
+	}
+	clamp(msg.Answer)
+	clamp(msg.Ns)
+	clamp(msg.Extra)
 }
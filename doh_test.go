@@ -0,0 +1,66 @@
+package ctrld
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheControlMaxAge(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		wantOK    bool
+		wantValue time.Duration
+	}{
+		{"simple", "max-age=300", true, 300 * time.Second},
+		{"whitespace", " max-age = 60 ", true, 60 * time.Second},
+		{"multi-directive", "no-cache, max-age=120, must-revalidate", true, 120 * time.Second},
+		{"case insensitive", "Max-Age=10", true, 10 * time.Second},
+		{"zero", "max-age=0", true, 0},
+		{"negative rejected", "max-age=-1", false, 0},
+		{"non-numeric rejected", "max-age=soon", false, 0},
+		{"missing directive", "no-cache", false, 0},
+		{"empty header", "", false, 0},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, ok := cacheControlMaxAge(tc.header)
+			assert.Equal(t, tc.wantOK, ok)
+			if tc.wantOK {
+				assert.Equal(t, tc.wantValue, got)
+			}
+		})
+	}
+}
+
+func TestClampMinTTL(t *testing.T) {
+	newMsg := func() *dns.Msg {
+		msg := new(dns.Msg)
+		msg.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Ttl: 600}}}
+		msg.Ns = []dns.RR{&dns.NS{Hdr: dns.RR_Header{Ttl: 600}}}
+		msg.Extra = []dns.RR{
+			&dns.A{Hdr: dns.RR_Header{Ttl: 600}},
+			&dns.OPT{Hdr: dns.RR_Header{Rrtype: dns.TypeOPT, Ttl: 1 << 24}},
+		}
+		return msg
+	}
+
+	msg := newMsg()
+	clampMinTTL(msg, 60*time.Second)
+
+	assert.EqualValues(t, 60, msg.Answer[0].Header().Ttl)
+	assert.EqualValues(t, 60, msg.Ns[0].Header().Ttl)
+	assert.EqualValues(t, 60, msg.Extra[0].Header().Ttl)
+	// The OPT pseudo-RR's TTL field encodes EDNS0 flags, not a cache
+	// lifetime, so it must be left alone.
+	assert.EqualValues(t, 1<<24, msg.Extra[1].Header().Ttl)
+
+	msg = newMsg()
+	clampMinTTL(msg, 3600*time.Second)
+	assert.EqualValues(t, 600, msg.Answer[0].Header().Ttl, "TTLs already below maxAge should be untouched")
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+package dns
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// resolvConfBackupPath holds the pre-ctrld contents of resolv.conf so
+// Close can restore them.
+const resolvConfBackupPath = resolvConfPath + ".pre-ctrld"
+
+// directManager rewrites /etc/resolv.conf in place. It is the
+// fallback used when no DNS management daemon is detected, and it
+// keeps a backup of whatever was there before so Close can put things
+// back the way it found them.
+type directManager struct {
+	backedUp bool
+	hadOrig  bool
+}
+
+func newDirectManager() *directManager {
+	return &directManager{}
+}
+
+func (m *directManager) SetDNS(config OSConfig) error {
+	if !m.backedUp {
+		orig, err := os.ReadFile(resolvConfPath)
+		switch {
+		case err == nil:
+			if err := os.WriteFile(resolvConfBackupPath, orig, 0644); err != nil {
+				return fmt.Errorf("backing up %s: %w", resolvConfPath, err)
+			}
+			m.hadOrig = true
+		case os.IsNotExist(err):
+			// Nothing to back up; Close should just remove the file
+			// we wrote rather than try to restore one that never
+			// existed.
+		default:
+			return fmt.Errorf("reading %s: %w", resolvConfPath, err)
+		}
+		m.backedUp = true
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# resolv.conf(5) file generated by ctrld\n")
+	for _, ip := range config.Nameservers {
+		fmt.Fprintf(&buf, "nameserver %s\n", ip)
+	}
+	var search []string
+	for _, dom := range config.SearchDomains {
+		search = append(search, dom.WithoutTrailingDot())
+	}
+	for _, dom := range config.MatchDomains {
+		search = append(search, dom.WithoutTrailingDot())
+	}
+	if len(search) > 0 {
+		fmt.Fprintf(&buf, "search %s\n", strings.Join(search, " "))
+	}
+
+	return atomicWriteFile(resolvConfPath, buf.Bytes(), 0644)
+}
+
+func (m *directManager) Close() error {
+	if !m.backedUp {
+		return nil
+	}
+	if !m.hadOrig {
+		if err := os.Remove(resolvConfPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing %s: %w", resolvConfPath, err)
+		}
+		return nil
+	}
+
+	orig, err := os.ReadFile(resolvConfBackupPath)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", resolvConfBackupPath, err)
+	}
+	if err := atomicWriteFile(resolvConfPath, orig, 0644); err != nil {
+		return fmt.Errorf("restoring %s: %w", resolvConfPath, err)
+	}
+	return os.Remove(resolvConfBackupPath)
+}
+
+func (m *directManager) Mode() string {
+	return "direct"
+}
+
+// atomicWriteFile writes data to a temp file in the same directory as
+// path, then renames it into place, so readers never observe a
+// half-written resolv.conf.
+func atomicWriteFile(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
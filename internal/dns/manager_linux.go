@@ -0,0 +1,181 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+package dns
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// resolvConfPath is where nearly every Linux distro expects the
+// resolver configuration to live.
+const resolvConfPath = "/etc/resolv.conf"
+
+// resolvOwner identifies which DNS manager, if any, appears to be
+// responsible for the current contents of /etc/resolv.conf.
+type resolvOwner int
+
+const (
+	ownerUnknown resolvOwner = iota
+	ownerResolved
+	ownerNetworkManager
+	ownerResolvconf
+)
+
+// Option customizes the OSConfigurator returned by NewOSConfigurator.
+type Option func(*options)
+
+type options struct {
+	disableMulticastDNS *bool
+}
+
+// WithDisableMulticastDNS controls whether the returned OSConfigurator
+// asks the backend to suppress LLMNR/mDNS resolution on the managed
+// interface. Only the NetworkManager backend reads it, and a nil value
+// (the default) makes it disable them. The systemd-resolved backend
+// always disables LLMNR/mDNS on the link whatever this is set to; the
+// resolvconf and direct backends never touch them.
+func WithDisableMulticastDNS(disable *bool) Option {
+	return func(o *options) {
+		o.disableMulticastDNS = disable
+	}
+}
+
+// NewOSConfigurator probes the running system for the DNS management
+// backend in charge of interfaceName and returns an OSConfigurator
+// that knows how to drive it.
+//
+// Detection follows the /etc/resolv.conf owner heuristic: the file's
+// header comments are scanned for a marker left by systemd-resolved,
+// resolvconf, or NetworkManager, and the corresponding DBus service
+// (or binary, for resolvconf) is then pinged to confirm the daemon is
+// actually alive before trusting it. If the apparent owner turns out
+// to be unreachable, or NetworkManager reports that it is itself
+// deferring to systemd-resolved, we fall back accordingly. When
+// nothing recognizable is found, NewOSConfigurator uses whichever of
+// systemd-resolved or NetworkManager is alive on the bus, and only
+// when neither is falls back to rewriting resolv.conf directly.
+func NewOSConfigurator(logf func(format string, args ...any), interfaceName string, opts ...Option) (OSConfigurator, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	owner := resolvConfOwner(resolvConfPath)
+	resolvedUp := dbusNameHasOwner("org.freedesktop.resolve1")
+	nmUp := dbusNameHasOwner("org.freedesktop.NetworkManager")
+
+	switch owner {
+	case ownerResolved:
+		if resolvedUp {
+			logf("dns: detected systemd-resolved, using it")
+			return newResolvedManager(logf, interfaceName)
+		}
+		logf("dns: resolv.conf looks like systemd-resolved's, but its bus is unreachable; falling back")
+	case ownerNetworkManager:
+		if nmUp && nmDefersToResolved() && resolvedUp {
+			logf("dns: NetworkManager is configured to defer to systemd-resolved, using it instead")
+			return newResolvedManager(logf, interfaceName)
+		}
+		if nmUp {
+			logf("dns: detected NetworkManager, using it")
+			return newNMManager(logf, interfaceName, o.disableMulticastDNS)
+		}
+		logf("dns: resolv.conf looks like NetworkManager's, but its bus is unreachable; falling back")
+	case ownerResolvconf:
+		if hasResolvconfBinary() {
+			logf("dns: detected resolvconf, using it")
+			return newResolvconfManager(interfaceName)
+		}
+	}
+
+	// Nothing we recognized owns resolv.conf, or the thing that does
+	// isn't actually running. As a last resort, prefer whichever of
+	// the DBus-based managers is actually alive, then fall all the
+	// way back to rewriting resolv.conf ourselves.
+	if resolvedUp {
+		logf("dns: no recognized owner for resolv.conf, but systemd-resolved is running; using it")
+		return newResolvedManager(logf, interfaceName)
+	}
+	if nmUp {
+		logf("dns: no recognized owner for resolv.conf, but NetworkManager is running; using it")
+		return newNMManager(logf, interfaceName, o.disableMulticastDNS)
+	}
+
+	logf("dns: no supported DNS manager detected, rewriting %s directly", resolvConfPath)
+	return newDirectManager(), nil
+}
+
+// resolvConfOwner inspects the header comments of the resolv.conf at
+// path and returns which manager, if any, appears to have written it.
+func resolvConfOwner(path string) resolvOwner {
+	f, err := os.Open(path)
+	if err != nil {
+		return ownerUnknown
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, ";") {
+			continue
+		}
+		switch {
+		case strings.Contains(line, "systemd-resolved"):
+			return ownerResolved
+		case strings.Contains(line, "NetworkManager"):
+			return ownerNetworkManager
+		case strings.Contains(line, "resolvconf"):
+			return ownerResolvconf
+		}
+	}
+	return ownerUnknown
+}
+
+// dbusNameHasOwner reports whether some process on the system bus
+// currently owns the given well-known name.
+func dbusNameHasOwner(name string) bool {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var hasOwner bool
+	err = conn.Object("org.freedesktop.DBus", "/org/freedesktop/DBus").CallWithContext(
+		ctx, "org.freedesktop.DBus.NameHasOwner", 0, name,
+	).Store(&hasOwner)
+	if err != nil {
+		return false
+	}
+	return hasOwner
+}
+
+// nmDefersToResolved reports whether NetworkManager's own DnsManager
+// object says it is running in "systemd-resolved" mode, meaning it
+// pushes its DNS settings to resolved rather than resolv.conf itself.
+func nmDefersToResolved() bool {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return false
+	}
+
+	obj := conn.Object("org.freedesktop.NetworkManager", dbus.ObjectPath("/org/freedesktop/NetworkManager/DnsManager"))
+	v, err := obj.GetProperty("org.freedesktop.NetworkManager.DnsManager.Mode")
+	if err != nil {
+		return false
+	}
+	mode, ok := v.Value().(string)
+	return ok && mode == "systemd-resolved"
+}
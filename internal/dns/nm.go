@@ -11,6 +11,8 @@ import (
 	"fmt"
 	"net"
 	"net/netip"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/godbus/dbus/v5"
@@ -26,22 +28,79 @@ const (
 
 // nmManager uses the NetworkManager DBus API.
 type nmManager struct {
+	logf          func(format string, args ...any)
 	interfaceName string
 	manager       dbus.BusObject
 	dnsManager    dbus.BusObject
+
+	// disableMulticastDNS controls whether trySet asks NetworkManager
+	// to turn off LLMNR/mDNS on the managed connection, on versions
+	// that support it. nil (the default) means "disable them", since
+	// that's what split-DNS users want; operators who rely on mDNS
+	// discovery can set it to a pointer to false.
+	disableMulticastDNS *bool
 }
 
-func newNMManager(interfaceName string) (*nmManager, error) {
+func newNMManager(logf func(format string, args ...any), interfaceName string, disableMulticastDNS *bool) (*nmManager, error) {
 	conn, err := dbus.SystemBus()
 	if err != nil {
 		return nil, err
 	}
 
-	return &nmManager{
-		interfaceName: interfaceName,
-		manager:       conn.Object("org.freedesktop.NetworkManager", dbus.ObjectPath("/org/freedesktop/NetworkManager")),
-		dnsManager:    conn.Object("org.freedesktop.NetworkManager", dbus.ObjectPath("/org/freedesktop/NetworkManager/DnsManager")),
-	}, nil
+	m := &nmManager{
+		logf:                logf,
+		interfaceName:       interfaceName,
+		manager:             conn.Object("org.freedesktop.NetworkManager", dbus.ObjectPath("/org/freedesktop/NetworkManager")),
+		dnsManager:          conn.Object("org.freedesktop.NetworkManager", dbus.ObjectPath("/org/freedesktop/NetworkManager/DnsManager")),
+		disableMulticastDNS: disableMulticastDNS,
+	}
+	logf("dns: NetworkManager LLMNR/mDNS suppression: %v", m.disableMulticastDNS == nil || *m.disableMulticastDNS)
+	return m, nil
+}
+
+// nmMinMulticastDNSVersion is the first NetworkManager release known
+// to accept the "llmnr" and "mdns" keys in the connection settings
+// map; older versions choke on them (#1870).
+const nmMinMulticastDNSVersion = "1.14"
+
+// nmSupportsMulticastDNSSettings reports whether the NetworkManager
+// behind m.manager is new enough to accept the llmnr/mdns connection
+// settings.
+func (m *nmManager) nmSupportsMulticastDNSSettings(ctx context.Context) bool {
+	v, err := m.manager.GetProperty("org.freedesktop.NetworkManager.Version")
+	if err != nil {
+		return false
+	}
+	version, ok := v.Value().(string)
+	if !ok {
+		return false
+	}
+	return compareVersions(version, nmMinMulticastDNSVersion) >= 0
+}
+
+// compareVersions does a numeric, dotted-component comparison of two
+// version strings, returning -1, 0, or 1. A "-" suffix on a component
+// (e.g. "-dev") is ignored; a component that is otherwise not a plain
+// number compares as 0.
+func compareVersions(a, b string) int {
+	as := strings.SplitN(a, ".", 3)
+	bs := strings.SplitN(b, ".", 3)
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var av, bv int
+		if i < len(as) {
+			av, _ = strconv.Atoi(strings.SplitN(as[i], "-", 2)[0])
+		}
+		if i < len(bs) {
+			bv, _ = strconv.Atoi(strings.SplitN(bs[i], "-", 2)[0])
+		}
+		if av != bv {
+			if av < bv {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
 }
 
 type nmConnectionSettings map[string]map[string]dbus.Variant
@@ -177,12 +236,24 @@ func (m *nmManager) trySet(ctx context.Context, config OSConfig) error {
 		search = append(search, "~.")
 	}
 
-	// Ideally we would like to disable LLMNR and mdns on the
-	// interface here, but older NetworkManagers don't understand
-	// those settings and choke on them, so we don't. Both LLMNR and
-	// mdns will fail since tailscale0 doesn't do multicast, so it's
-	// effectively fine. We used to try and enforce LLMNR and mdns
-	// settings here, but that led to #1870.
+	// Modern NetworkManager (>= 1.14) accepts "llmnr" and "mdns" in
+	// the connection settings group; leaving them at their defaults
+	// lets the kernel answer .local and single-label queries straight
+	// off the LAN instead of routing them through us, which leaks
+	// split-horizon names. Older NetworkManagers choke on those keys
+	// (#1870), so only set them when the running daemon understands
+	// them, and only unless the operator opted out via
+	// disableMulticastDNS.
+	disableMulticastDNS := m.disableMulticastDNS == nil || *m.disableMulticastDNS
+	if disableMulticastDNS && m.nmSupportsMulticastDNSSettings(ctx) {
+		connMap, ok := settings["connection"]
+		if !ok {
+			connMap = map[string]dbus.Variant{}
+			settings["connection"] = connMap
+		}
+		connMap["llmnr"] = dbus.MakeVariant(int32(0))
+		connMap["mdns"] = dbus.MakeVariant(int32(0))
+	}
 
 	ipv4Map := settings["ipv4"]
 	ipv4Map["dns"] = dbus.MakeVariant(dnsv4)
@@ -0,0 +1,60 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+package dns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want int
+	}{
+		{"equal", "1.14", "1.14", 0},
+		{"minor less", "1.2", "1.14", -1},
+		{"minor greater", "1.14", "1.2", 1},
+		{"patch less", "1.14.0", "1.14.1", -1},
+		{"dev suffix ignored", "1.14-dev", "1.14", 0},
+		{"older major", "0.9", "1.14", -1},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, compareVersions(tc.a, tc.b))
+		})
+	}
+}
+
+// TestNmSupportsMulticastDNSSettings checks the version gate that
+// nmSupportsMulticastDNSSettings applies, without a DBus connection:
+// compareVersions against nmMinMulticastDNSVersion.
+func TestNmSupportsMulticastDNSSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		want    bool
+	}{
+		{"below min", "1.2.0", false},
+		{"at min", "1.14", true},
+		{"above min", "1.22.0", true},
+		{"dev suffix", "1.14-dev", true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := compareVersions(tc.version, nmMinMulticastDNSVersion) >= 0
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+package dns
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+)
+
+// resolvconfInterfaceID is the identifier resolvconf(8) uses to tell
+// our records apart from every other program feeding it nameservers.
+const resolvconfInterfaceID = "tailscale.inet"
+
+// resolvconfManager drives the Debian/Ubuntu "resolvconf" family of
+// tools (openresolv is API-compatible) by shelling out to the
+// resolvconf binary on the PATH.
+type resolvconfManager struct {
+	interfaceName string
+}
+
+func newResolvconfManager(interfaceName string) (*resolvconfManager, error) {
+	return &resolvconfManager{interfaceName: interfaceName}, nil
+}
+
+func hasResolvconfBinary() bool {
+	_, err := exec.LookPath("resolvconf")
+	return err == nil
+}
+
+func (m *resolvconfManager) SetDNS(config OSConfig) error {
+	var stdin bytes.Buffer
+	for _, ip := range config.Nameservers {
+		fmt.Fprintf(&stdin, "nameserver %s\n", ip)
+	}
+	for _, dom := range config.SearchDomains {
+		fmt.Fprintf(&stdin, "search %s\n", dom.WithoutTrailingDot())
+	}
+	for _, dom := range config.MatchDomains {
+		fmt.Fprintf(&stdin, "search %s\n", dom.WithoutTrailingDot())
+	}
+
+	cmd := exec.Command("resolvconf", "-a", resolvconfInterfaceID, "-m", "0", "-x")
+	cmd.Stdin = &stdin
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("resolvconf -a: %w: %s", err, out)
+	}
+	return nil
+}
+
+func (m *resolvconfManager) Close() error {
+	cmd := exec.Command("resolvconf", "-d", resolvconfInterfaceID)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("resolvconf -d: %w: %s", err, out)
+	}
+	return nil
+}
+
+func (m *resolvconfManager) Mode() string {
+	return "resolvconf"
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/godbus/dbus/v5"
+	"golang.org/x/sys/unix"
+)
+
+// resolvedManager uses the systemd-resolved DBus API.
+type resolvedManager struct {
+	logf       func(format string, args ...any)
+	ifaceName  string
+	ifaceIndex int32
+	resolved   dbus.BusObject
+}
+
+func newResolvedManager(logf func(format string, args ...any), interfaceName string) (*resolvedManager, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return nil, err
+	}
+
+	iface, err := net.InterfaceByName(interfaceName)
+	if err != nil {
+		return nil, fmt.Errorf("getting interface %q: %w", interfaceName, err)
+	}
+
+	return &resolvedManager{
+		logf:       logf,
+		ifaceName:  interfaceName,
+		ifaceIndex: int32(iface.Index),
+		resolved:   conn.Object("org.freedesktop.resolve1", dbus.ObjectPath("/org/freedesktop/resolve1")),
+	}, nil
+}
+
+// resolvedLinkDNS mirrors the (family, address-bytes) pairs that
+// SetLinkDNS expects.
+type resolvedLinkDNS struct {
+	Family  int32
+	Address []byte
+}
+
+func (m *resolvedManager) SetDNS(config OSConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), reconfigTimeout)
+	defer cancel()
+
+	var dnsServers []resolvedLinkDNS
+	for _, ip := range config.Nameservers {
+		if ip.Is4() {
+			b := ip.As4()
+			dnsServers = append(dnsServers, resolvedLinkDNS{Family: unix.AF_INET, Address: b[:]})
+		} else {
+			b := ip.As16()
+			dnsServers = append(dnsServers, resolvedLinkDNS{Family: unix.AF_INET6, Address: b[:]})
+		}
+	}
+	if call := m.resolved.CallWithContext(ctx, "org.freedesktop.resolve1.Manager.SetLinkDNS", 0, m.ifaceIndex, dnsServers); call.Err != nil {
+		return fmt.Errorf("SetLinkDNS: %w", call.Err)
+	}
+
+	// Domains are expressed as (domain, routingOnly) pairs: a
+	// routing-only domain participates in split-DNS routing decisions
+	// but is not appended to the system's search list.
+	type domain struct {
+		Name        string
+		RoutingOnly bool
+	}
+	var domains []domain
+	for _, d := range config.SearchDomains {
+		domains = append(domains, domain{Name: d.WithoutTrailingDot(), RoutingOnly: false})
+	}
+	for _, d := range config.MatchDomains {
+		domains = append(domains, domain{Name: d.WithoutTrailingDot(), RoutingOnly: true})
+	}
+	if len(config.MatchDomains) == 0 {
+		domains = append(domains, domain{Name: ".", RoutingOnly: true})
+	}
+	if call := m.resolved.CallWithContext(ctx, "org.freedesktop.resolve1.Manager.SetLinkDomains", 0, m.ifaceIndex, domains); call.Err != nil {
+		return fmt.Errorf("SetLinkDomains: %w", call.Err)
+	}
+
+	// Only claim the default route when we're not doing split DNS;
+	// otherwise resolved would start sending every unmatched query to
+	// us too.
+	defaultRoute := len(config.MatchDomains) == 0
+	if call := m.resolved.CallWithContext(ctx, "org.freedesktop.resolve1.Manager.SetLinkDefaultRoute", 0, m.ifaceIndex, defaultRoute); call.Err != nil {
+		return fmt.Errorf("SetLinkDefaultRoute: %w", call.Err)
+	}
+
+	// LLMNR and mDNS would otherwise race our upstream for answers to
+	// single-label and .local names; resolved lets us turn both off
+	// per-link.
+	if call := m.resolved.CallWithContext(ctx, "org.freedesktop.resolve1.Manager.SetLinkLLMNR", 0, m.ifaceIndex, "no"); call.Err != nil {
+		return fmt.Errorf("SetLinkLLMNR: %w", call.Err)
+	}
+	if call := m.resolved.CallWithContext(ctx, "org.freedesktop.resolve1.Manager.SetLinkMulticastDNS", 0, m.ifaceIndex, "no"); call.Err != nil {
+		return fmt.Errorf("SetLinkMulticastDNS: %w", call.Err)
+	}
+
+	return nil
+}
+
+func (m *resolvedManager) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), reconfigTimeout)
+	defer cancel()
+
+	call := m.resolved.CallWithContext(ctx, "org.freedesktop.resolve1.Manager.RevertLink", 0, m.ifaceIndex)
+	if call.Err != nil {
+		m.logf("resolved: RevertLink on close: %v", call.Err)
+	}
+	return nil
+}
+
+func (m *resolvedManager) Mode() string {
+	return "systemd-resolved"
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dns
+
+import (
+	"net/netip"
+	"sort"
+	"strings"
+
+	"tailscale.com/util/dnsname"
+)
+
+// SplitDNSRule binds a domain suffix from a ctrld.toml policy rule to
+// the upstream that should answer queries under it. An empty Domain
+// is the wildcard rule: the upstream every other query falls back
+// to. Domain may be written either as a bare suffix ("corp.example")
+// or with a leading wildcard label ("*.corp.example"), matching the
+// two forms ctrld.toml accepts.
+type SplitDNSRule struct {
+	Domain   string
+	Upstream string
+}
+
+// SplitDNSListener is one of ctrld's own loopback listeners, each
+// dedicated to forwarding queries for a group of domains to a single
+// upstream.
+type SplitDNSListener struct {
+	Addr     netip.AddrPort
+	Upstream string
+}
+
+// SplitDNSPolicy computes, from ctrld's parsed listener/upstream/rule
+// configuration, the OSConfig each of ctrld's loopback listeners
+// needs to be registered with so the OS resolver stack routes queries
+// to the right one, instead of making the caller hand-build an
+// OSConfig itself.
+type SplitDNSPolicy struct {
+	// Listeners maps an upstream name to the loopback listener ctrld
+	// binds for it.
+	Listeners []SplitDNSListener
+	// Rules holds every domain: matcher from ctrld's policies, plus
+	// the wildcard rule (empty Domain) if one is configured.
+	Rules []SplitDNSRule
+	// SearchDomains is copied verbatim into every OSConfig produced.
+	SearchDomains []dnsname.FQDN
+	// Logf receives a line for every rule Resolve has to drop because
+	// it can't be honored (malformed domain, or a domain already
+	// claimed by another upstream), and one line per upstream with no
+	// matching listener, covering all of its rules. Defaults to a
+	// no-op if nil.
+	Logf func(format string, args ...any)
+}
+
+func (p *SplitDNSPolicy) logf(format string, args ...any) {
+	if p.Logf != nil {
+		p.Logf(format, args...)
+	}
+}
+
+// Resolve partitions Rules by upstream and returns the OSConfig each
+// upstream's loopback listener should be handed to OSConfigurator.
+// SetDNS. The wildcard upstream, if any, gets back an OSConfig with
+// no MatchDomains: both nmManager and resolvedManager already treat
+// an empty MatchDomains as "claim the default route", so the wildcard
+// listener ends up seeing every otherwise-unmatched query.
+//
+// When rules from different listeners would otherwise both match a
+// query - e.g. "corp.example" and "dev.corp.example" both matching
+// "host.dev.corp.example" - the longer, more specific suffix wins.
+// Any rule Resolve can't honor - a malformed domain, a duplicate
+// domain claimed by more than one upstream, or an upstream with no
+// corresponding listener - is dropped with a line through Logf rather
+// than silently.
+func (p *SplitDNSPolicy) Resolve() map[string]OSConfig {
+	byUpstream := make(map[string]SplitDNSListener, len(p.Listeners))
+	for _, l := range p.Listeners {
+		byUpstream[l.Upstream] = l
+	}
+
+	var wildcardUpstream string
+	type domainRule struct {
+		domain   dnsname.FQDN
+		upstream string
+	}
+	var domains []domainRule
+	for _, r := range p.Rules {
+		if r.Domain == "" {
+			wildcardUpstream = r.Upstream
+			continue
+		}
+		bare := strings.TrimPrefix(strings.TrimPrefix(r.Domain, "*."), "*")
+		fqdn, err := dnsname.ToFQDN(bare)
+		if err != nil {
+			p.logf("dns: dropping split-DNS rule for upstream %q: invalid domain %q: %v", r.Upstream, r.Domain, err)
+			continue
+		}
+		domains = append(domains, domainRule{domain: fqdn, upstream: r.Upstream})
+	}
+
+	// Sort longest domain first, so a more specific rule claims its
+	// domain before a shorter, less specific one gets the chance to.
+	// Ties are broken first on the domain name and then on the
+	// upstream, so the outcome never depends on the order Rules were
+	// supplied in - including when two rules name the exact same
+	// domain for different upstreams.
+	sort.Slice(domains, func(i, j int) bool {
+		if len(domains[i].domain) != len(domains[j].domain) {
+			return len(domains[i].domain) > len(domains[j].domain)
+		}
+		if domains[i].domain != domains[j].domain {
+			return domains[i].domain < domains[j].domain
+		}
+		return domains[i].upstream < domains[j].upstream
+	})
+
+	claimedBy := make(map[dnsname.FQDN]string, len(domains))
+	matchDomains := make(map[string][]dnsname.FQDN, len(byUpstream))
+	for _, d := range domains {
+		if owner, ok := claimedBy[d.domain]; ok {
+			if owner != d.upstream {
+				p.logf("dns: split-DNS rule for %q names both %q and %q; keeping %q", d.domain, owner, d.upstream, owner)
+			}
+			continue
+		}
+		claimedBy[d.domain] = d.upstream
+		matchDomains[d.upstream] = append(matchDomains[d.upstream], d.domain)
+	}
+
+	configs := make(map[string]OSConfig, len(byUpstream))
+	for upstream, l := range byUpstream {
+		cfg := OSConfig{
+			Nameservers:   []netip.Addr{l.Addr.Addr()},
+			SearchDomains: p.SearchDomains,
+			MatchDomains:  matchDomains[upstream],
+		}
+		if upstream == wildcardUpstream {
+			cfg.MatchDomains = nil
+		}
+		configs[upstream] = cfg
+		delete(matchDomains, upstream)
+	}
+
+	// Whatever's left in matchDomains referenced an upstream with no
+	// listener configured for it; that's a config bug, not something
+	// to route around silently.
+	for upstream, doms := range matchDomains {
+		p.logf("dns: dropping split-DNS rules for upstream %q (%d domain(s)): no listener configured", upstream, len(doms))
+	}
+	if wildcardUpstream != "" {
+		if _, ok := byUpstream[wildcardUpstream]; !ok {
+			p.logf("dns: dropping wildcard split-DNS rule for upstream %q: no listener configured", wildcardUpstream)
+		}
+	}
+
+	return configs
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dns
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"tailscale.com/util/dnsname"
+)
+
+func mustFQDN(t *testing.T, s string) dnsname.FQDN {
+	t.Helper()
+	fqdn, err := dnsname.ToFQDN(s)
+	if err != nil {
+		t.Fatalf("ToFQDN(%q): %v", s, err)
+	}
+	return fqdn
+}
+
+func TestSplitDNSPolicyResolve(t *testing.T) {
+	loopback := func(port int) netip.AddrPort {
+		return netip.AddrPortFrom(netip.MustParseAddr("127.0.0.1"), uint16(port))
+	}
+
+	t.Run("wildcard collapses to no MatchDomains", func(t *testing.T) {
+		p := &SplitDNSPolicy{
+			Listeners: []SplitDNSListener{{Addr: loopback(5353), Upstream: "default"}},
+			Rules:     []SplitDNSRule{{Domain: "", Upstream: "default"}},
+		}
+		cfg := p.Resolve()["default"]
+		assert.Empty(t, cfg.MatchDomains)
+		assert.Equal(t, []netip.Addr{loopback(5353).Addr()}, cfg.Nameservers)
+	})
+
+	t.Run("explicit matcher keeps its own listener separate from the default", func(t *testing.T) {
+		p := &SplitDNSPolicy{
+			Listeners: []SplitDNSListener{
+				{Addr: loopback(5353), Upstream: "default"},
+				{Addr: loopback(5354), Upstream: "corp"},
+			},
+			Rules: []SplitDNSRule{
+				{Domain: "", Upstream: "default"},
+				{Domain: "*.corp.example", Upstream: "corp"},
+			},
+		}
+		configs := p.Resolve()
+		assert.Empty(t, configs["default"].MatchDomains)
+		assert.Equal(t, []dnsname.FQDN{mustFQDN(t, "corp.example")}, configs["corp"].MatchDomains)
+	})
+
+	t.Run("wildcard-form and bare-form domains are equivalent", func(t *testing.T) {
+		resolve := func(domain string) []dnsname.FQDN {
+			p := &SplitDNSPolicy{
+				Listeners: []SplitDNSListener{{Addr: loopback(5353), Upstream: "corp"}},
+				Rules:     []SplitDNSRule{{Domain: domain, Upstream: "corp"}},
+			}
+			return p.Resolve()["corp"].MatchDomains
+		}
+		assert.Equal(t, []dnsname.FQDN{mustFQDN(t, "corp.example")}, resolve("corp.example"))
+		assert.Equal(t, []dnsname.FQDN{mustFQDN(t, "corp.example")}, resolve("*.corp.example"))
+	})
+
+	t.Run("invalid domain is dropped and logged, not fatal", func(t *testing.T) {
+		var logged []string
+		p := &SplitDNSPolicy{
+			Listeners: []SplitDNSListener{{Addr: loopback(5353), Upstream: "corp"}},
+			Rules: []SplitDNSRule{
+				{Domain: "not a domain!", Upstream: "corp"},
+				{Domain: "corp.example", Upstream: "corp"},
+			},
+			Logf: func(format string, args ...any) { logged = append(logged, format) },
+		}
+		cfg := p.Resolve()["corp"]
+		assert.Equal(t, []dnsname.FQDN{mustFQDN(t, "corp.example")}, cfg.MatchDomains)
+		assert.NotEmpty(t, logged)
+	})
+
+	t.Run("duplicate domain across upstreams resolves deterministically", func(t *testing.T) {
+		p := func() *SplitDNSPolicy {
+			return &SplitDNSPolicy{
+				Listeners: []SplitDNSListener{
+					{Addr: loopback(5353), Upstream: "a"},
+					{Addr: loopback(5354), Upstream: "b"},
+				},
+				Rules: []SplitDNSRule{
+					{Domain: "corp.example", Upstream: "b"},
+					{Domain: "corp.example", Upstream: "a"},
+				},
+			}
+		}
+
+		first := p().Resolve()
+		// Swap the input order; the winner must not change.
+		p2 := p()
+		p2.Rules[0], p2.Rules[1] = p2.Rules[1], p2.Rules[0]
+		second := p2.Resolve()
+
+		winnerHasIt := len(first["a"].MatchDomains) == 1
+		if winnerHasIt {
+			assert.Len(t, first["b"].MatchDomains, 0)
+			assert.Len(t, second["a"].MatchDomains, 1)
+			assert.Len(t, second["b"].MatchDomains, 0)
+		} else {
+			assert.Len(t, first["b"].MatchDomains, 1)
+			assert.Len(t, second["a"].MatchDomains, 0)
+			assert.Len(t, second["b"].MatchDomains, 1)
+		}
+	})
+
+	t.Run("rule for an upstream with no listener is dropped and logged", func(t *testing.T) {
+		var logged []string
+		p := &SplitDNSPolicy{
+			Listeners: []SplitDNSListener{{Addr: loopback(5353), Upstream: "default"}},
+			Rules: []SplitDNSRule{
+				{Domain: "", Upstream: "default"},
+				{Domain: "corp.example", Upstream: "missing"},
+			},
+			Logf: func(format string, args ...any) { logged = append(logged, format) },
+		}
+		configs := p.Resolve()
+		_, ok := configs["missing"]
+		assert.False(t, ok)
+		assert.NotEmpty(t, logged)
+	})
+
+	t.Run("wildcard rule for an upstream with no listener is dropped and logged", func(t *testing.T) {
+		var logged []string
+		p := &SplitDNSPolicy{
+			Listeners: nil,
+			Rules:     []SplitDNSRule{{Domain: "", Upstream: "default"}},
+			Logf:      func(format string, args ...any) { logged = append(logged, format) },
+		}
+		configs := p.Resolve()
+		assert.Empty(t, configs)
+		assert.NotEmpty(t, logged)
+	})
+}
@@ -1,9 +1,13 @@
 package ctrld
 
 import (
+	"context"
 	"net"
 	"os"
+	"reflect"
+	"sync"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
@@ -69,3 +73,143 @@ func adapterAddresses() ([]*windows.IpAdapterAddresses, error) {
 	}
 	return aas, nil
 }
+
+var (
+	modiphlpapi                      = windows.NewLazySystemDLL("iphlpapi.dll")
+	procNotifyIpInterfaceChange      = modiphlpapi.NewProc("NotifyIpInterfaceChange")
+	procNotifyUnicastIpAddressChange = modiphlpapi.NewProc("NotifyUnicastIpAddressChange")
+	procCancelMibChangeNotify2       = modiphlpapi.NewProc("CancelMibChangeNotify2")
+)
+
+// nameserverWatchDebounce absorbs the burst of change notifications
+// Windows fires while an interface is coming up or a DHCP lease is
+// renewing, so we only recompute and publish once things settle.
+const nameserverWatchDebounce = 200 * time.Millisecond
+
+// WatchNameservers watches for changes to the system's resolver list
+// and pushes the new list to the returned channel whenever it
+// changes, instead of making callers poll nameservers() on a timer.
+//
+// It registers for both NotifyIpInterfaceChange (interface up/down,
+// DNS-server changes) and NotifyUnicastIpAddressChange (address
+// changes that can imply the resolver list changed too), coalesces
+// bursts of callbacks with a short debounce, and diffs against the
+// last published list so callers only see real changes. The
+// registrations are torn down when ctx is canceled.
+func WatchNameservers(ctx context.Context) (<-chan []string, error) {
+	w := &nameserverWatcher{
+		ch:      make(chan []string, 1),
+		last:    nameservers(),
+		timer:   time.NewTimer(time.Hour),
+		stopped: make(chan struct{}),
+	}
+	w.timer.Stop()
+
+	cb := syscall.NewCallback(w.onChange)
+
+	var ifaceHandle uintptr
+	r, _, err := procNotifyIpInterfaceChange.Call(
+		uintptr(syscall.AF_UNSPEC), cb, 0, 0, uintptr(unsafe.Pointer(&ifaceHandle)),
+	)
+	if r != 0 {
+		return nil, os.NewSyscallError("NotifyIpInterfaceChange", err)
+	}
+
+	var addrHandle uintptr
+	r, _, err = procNotifyUnicastIpAddressChange.Call(
+		uintptr(syscall.AF_UNSPEC), cb, 0, 0, uintptr(unsafe.Pointer(&addrHandle)),
+	)
+	if r != 0 {
+		procCancelMibChangeNotify2.Call(ifaceHandle)
+		return nil, os.NewSyscallError("NotifyUnicastIpAddressChange", err)
+	}
+
+	go w.debounceLoop()
+
+	go func() {
+		<-ctx.Done()
+		procCancelMibChangeNotify2.Call(ifaceHandle)
+		procCancelMibChangeNotify2.Call(addrHandle)
+		w.stop()
+	}()
+
+	return w.ch, nil
+}
+
+// nameserverWatcher holds the state shared between the DLL callback
+// trampoline and the goroutine that debounces and publishes changes.
+type nameserverWatcher struct {
+	ch      chan []string
+	timer   *time.Timer
+	stopped chan struct{}
+
+	mu   sync.Mutex
+	last []string
+	done bool
+}
+
+// onChange is invoked directly by iphlpapi on its own thread for
+// every interface or address change; it must return quickly, so all
+// it does is (re)arm the debounce timer.
+func (w *nameserverWatcher) onChange(callerContext, row uintptr, notificationType uint32) uintptr {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.done {
+		return 0
+	}
+	w.timer.Reset(nameserverWatchDebounce)
+	return 0
+}
+
+func (w *nameserverWatcher) debounceLoop() {
+	for {
+		select {
+		case <-w.stopped:
+			return
+		case <-w.timer.C:
+		}
+
+		ns := nameservers()
+
+		w.mu.Lock()
+		changed := !reflect.DeepEqual(ns, w.last)
+		if changed {
+			w.last = ns
+		}
+		w.mu.Unlock()
+
+		if !changed {
+			continue
+		}
+		select {
+		case w.ch <- ns:
+		case <-w.stopped:
+			return
+		default:
+			// Drop the stale pending value and replace it with the
+			// fresh one; callers only care about the latest resolver
+			// list.
+			select {
+			case <-w.ch:
+			default:
+			}
+			select {
+			case w.ch <- ns:
+			case <-w.stopped:
+				return
+			}
+		}
+	}
+}
+
+// stop tears down the watcher. The channel returned from
+// WatchNameservers is deliberately left open: callers already know to
+// stop reading it once their ctx is done, and closing it here would
+// race with an in-flight send from debounceLoop.
+func (w *nameserverWatcher) stop() {
+	w.mu.Lock()
+	w.done = true
+	w.mu.Unlock()
+	w.timer.Stop()
+	close(w.stopped)
+}
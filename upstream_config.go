@@ -0,0 +1,57 @@
+package ctrld
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lucas-clemente/quic-go/http3"
+)
+
+// ResolverType identifies the wire protocol ctrld uses to reach an
+// upstream, as set by the `type` key in a ctrld.toml [upstream.X]
+// table.
+type ResolverType string
+
+const (
+	ResolverTypeDOH    ResolverType = "doh"
+	ResolverTypeDOH3   ResolverType = "doh3"
+	ResolverTypeDOT    ResolverType = "dot"
+	ResolverTypeDOQ    ResolverType = "doq"
+	ResolverTypeLegacy ResolverType = "legacy"
+)
+
+// UpstreamConfig describes a single upstream resolver, as configured
+// by a ctrld.toml [upstream.X] table.
+type UpstreamConfig struct {
+	Name     string       `mapstructure:"name" toml:"name,omitempty"`
+	Type     ResolverType `mapstructure:"type" toml:"type,omitempty"`
+	Endpoint string       `mapstructure:"endpoint" toml:"endpoint,omitempty"`
+
+	// DOHMethod selects the HTTP method dohResolver uses for DoH and
+	// DoH3 queries: "get" sends the query as a base64url "?dns="
+	// parameter, "post" sends it as the request body per RFC 8484,
+	// and "auto" (the default) picks POST once the packed query
+	// would make the URL uncomfortably long, or once the server has
+	// already rejected a GET for exactly that reason.
+	DOHMethod string `mapstructure:"doh_method" toml:"doh_method,omitempty"`
+
+	transport         *http.Transport
+	http3RoundTripper *http3.RoundTripper
+}
+
+// Init normalizes and validates the fields of uc that need it once
+// ctrld.toml has been unmarshalled into it. The config loader calls
+// this for every configured upstream before resolvers are built from
+// them.
+func (uc *UpstreamConfig) Init() error {
+	switch strings.ToLower(uc.DOHMethod) {
+	case "":
+		uc.DOHMethod = "auto"
+	case "get", "post", "auto":
+		uc.DOHMethod = strings.ToLower(uc.DOHMethod)
+	default:
+		return fmt.Errorf("upstream %q: invalid doh_method %q: must be one of \"get\", \"post\", \"auto\"", uc.Name, uc.DOHMethod)
+	}
+	return nil
+}